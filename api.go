@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// API is the HTTP control surface around a running EMS. It lets external orchestrators force
+// the ESS into a temporary boost charge/discharge, read back current state, and curtail PV
+// production, turning the daemon from a batch simulator into an integrable component.
+type API struct {
+	EMS *EMS
+}
+
+// Handler returns the mux serving the control surface.
+func (a *API) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ess/boost", a.handleBoost)
+	mux.HandleFunc("/state", a.handleState)
+	mux.HandleFunc("/pv/curtail", a.handleCurtail)
+	mux.HandleFunc("/metrics", a.handleMetrics)
+
+	return mux
+}
+
+// ListenAndServe runs the control surface on addr until ctx is done.
+func (a *API) ListenAndServe(ctx context.Context, addr string) error {
+	server := &http.Server{Addr: addr, Handler: a.Handler()}
+
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+
+	return nil
+}
+
+// boostRequest is the body of POST /ess/boost.
+type boostRequest struct {
+	Mode     string  `json:"mode"` // "charge" or "discharge"
+	Duration string  `json:"duration"`
+	Power    float64 `json:"power"` // kW
+}
+
+func (a *API) handleBoost(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	var req boostRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	duration, err := time.ParseDuration(req.Duration)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	var mode Mode
+	switch req.Mode {
+	case "charge":
+		mode = ModeCharging
+	case "discharge":
+		mode = ModeDischarging
+	default:
+		http.Error(w, fmt.Sprintf("unknown boost mode %q", req.Mode), http.StatusBadRequest)
+
+		return
+	}
+
+	a.EMS.mu.Lock()
+	a.EMS.ESS.BoostMode = mode
+	a.EMS.ESS.BoostPower = req.Power
+	a.EMS.ESS.BoostUntil = time.Now().Add(duration)
+	a.EMS.mu.Unlock()
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *API) handleState(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	a.EMS.mu.Lock()
+	state := a.EMS.String()
+	a.EMS.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"state": state})
+}
+
+func (a *API) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(a.EMS.Metrics())
+}
+
+// curtailRequest is the body of POST /pv/curtail.
+type curtailRequest struct {
+	P float64 `json:"p"` // kW
+}
+
+func (a *API) handleCurtail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	var req curtailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	a.EMS.mu.Lock()
+	a.EMS.PV.SetSetpoint(min(req.P, a.EMS.PV.Pprod))
+	a.EMS.mu.Unlock()
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandleBoost(t *testing.T) {
+	a := &API{EMS: &EMS{ESS: ESS{PmaxCh: -10, PmaxDisch: 10}}}
+
+	body := strings.NewReader(`{"mode":"discharge","duration":"1m","power":5}`)
+	req := httptest.NewRequest(http.MethodPost, "/ess/boost", body)
+	w := httptest.NewRecorder()
+
+	a.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusNoContent)
+	}
+
+	if a.EMS.ESS.BoostMode != ModeDischarging {
+		t.Errorf("BoostMode = %v, want %v", a.EMS.ESS.BoostMode, ModeDischarging)
+	}
+
+	if a.EMS.ESS.BoostPower != 5 {
+		t.Errorf("BoostPower = %v, want 5", a.EMS.ESS.BoostPower)
+	}
+
+	if !a.EMS.ESS.BoostUntil.After(time.Now()) {
+		t.Errorf("BoostUntil = %v, want a time in the future", a.EMS.ESS.BoostUntil)
+	}
+}
+
+func TestHandleBoostInvalidMode(t *testing.T) {
+	a := &API{EMS: &EMS{}}
+
+	body := strings.NewReader(`{"mode":"sideways","duration":"1m","power":5}`)
+	req := httptest.NewRequest(http.MethodPost, "/ess/boost", body)
+	w := httptest.NewRecorder()
+
+	a.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestHandleBoostWrongMethod(t *testing.T) {
+	a := &API{EMS: &EMS{}}
+
+	req := httptest.NewRequest(http.MethodGet, "/ess/boost", nil)
+	w := httptest.NewRecorder()
+
+	a.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestHandleState(t *testing.T) {
+	a := &API{EMS: &EMS{ESS: ESS{P: 1.5}}}
+
+	req := httptest.NewRequest(http.MethodGet, "/state", nil)
+	w := httptest.NewRecorder()
+
+	a.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	var resp map[string]string
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	if !strings.Contains(resp["state"], "P: 1.50") {
+		t.Errorf("state = %q, want it to mention P: 1.50", resp["state"])
+	}
+}
+
+func TestHandleMetrics(t *testing.T) {
+	ems := &EMS{}
+	ems.metrics = EnergyMetrics{SolarKWh: 1}
+	a := &API{EMS: ems}
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	w := httptest.NewRecorder()
+
+	a.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	var got EnergyMetrics
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	if got.SolarKWh != 1 {
+		t.Errorf("SolarKWh = %v, want 1", got.SolarKWh)
+	}
+}
+
+func TestHandleCurtail(t *testing.T) {
+	a := &API{EMS: &EMS{PV: PV{Pprod: 10}}}
+
+	body := strings.NewReader(`{"p":4}`)
+	req := httptest.NewRequest(http.MethodPost, "/pv/curtail", body)
+	w := httptest.NewRecorder()
+
+	a.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusNoContent)
+	}
+
+	if a.EMS.PV.SetPointP != 4 {
+		t.Errorf("PV.SetPointP = %v, want 4", a.EMS.PV.SetPointP)
+	}
+}
+
+// TestHandleCurtailClampsAboveProduction covers handleCurtail's clamp via min(req.P, Pprod): a
+// curtail request above current production must not raise the setpoint past it.
+func TestHandleCurtailClampsAboveProduction(t *testing.T) {
+	a := &API{EMS: &EMS{PV: PV{Pprod: 3}}}
+
+	body := strings.NewReader(`{"p":9}`)
+	req := httptest.NewRequest(http.MethodPost, "/pv/curtail", body)
+	w := httptest.NewRecorder()
+
+	a.Handler().ServeHTTP(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusNoContent)
+	}
+
+	if a.EMS.PV.SetPointP != 3 {
+		t.Errorf("PV.SetPointP = %v, want 3 (clamped to Pprod)", a.EMS.PV.SetPointP)
+	}
+}
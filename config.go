@@ -11,9 +11,119 @@ type config struct {
 	PV       PV      `json:"pv" yaml:"pv" toml:"pv"`
 	POC      POC     `json:"poc" yaml:"poc" toml:"poc"`
 	PMaxSite float64 `json:"pmaxsite" yaml:"pmaxsite" toml:"pmaxsite"`
+
+	// Strategy selects the ESS dispatch heuristic: "threshold" (default), "tariff" or "mpc".
+	Strategy string       `json:"strategy" yaml:"strategy" toml:"strategy"`
+	Tariff   TariffConfig `json:"tariff" yaml:"tariff" toml:"tariff"`
+	MPC      MPCConfig    `json:"mpc" yaml:"mpc" toml:"mpc"`
+
+	// APIAddr is the listen address (e.g. ":8080") for the HTTP control surface. Left empty,
+	// the control surface is not started.
+	APIAddr string `json:"apiaddr" yaml:"apiaddr" toml:"apiaddr"`
+
+	// MetricsSnapshotPath, if set, is where EnergyMetrics are persisted so cumulative KPIs
+	// survive a restart. Left empty, metrics only accumulate for the life of the process.
+	MetricsSnapshotPath string `json:"metricssnapshotpath" yaml:"metricssnapshotpath" toml:"metricssnapshotpath"`
+
+	// tariff caches the Tariff built from Tariff.Build during Validate, so run does not have to
+	// build it (and for "awattar", fetch it over the network) a second time. Use BuiltTariff to
+	// read it back.
+	tariff Tariff
+}
+
+// Validate checks invariants across the config that cannot be expressed as struct tags. It also
+// builds the configured Tariff and caches it on c: callers needing the built Tariff (e.g. run)
+// should use BuiltTariff instead of calling Tariff.Build again, since for "awattar" that performs
+// a live HTTP fetch.
+func (c *config) Validate() error {
+	if c.ESS.DwellTime < 0 {
+		return ErrInvalidConfig{Field: "ess.dwelltime", Reason: "must not be negative"}
+	}
+
+	if c.ESS.SOCLow < 0 || c.ESS.SOCLow > 1 || c.ESS.SOCHigh < 0 || c.ESS.SOCHigh > 1 {
+		return ErrInvalidConfig{Field: "ess.soclow/sochigh", Reason: "must be a fraction between 0 and 1"}
+	}
+
+	if c.ESS.SOCLow > c.ESS.SOCHigh {
+		return ErrInvalidConfig{Field: "ess.soclow/sochigh", Reason: "soclow must not exceed sochigh"}
+	}
+
+	if c.ESS.ChargeEfficiency <= 0 || c.ESS.ChargeEfficiency > 1 {
+		return ErrInvalidConfig{Field: "ess.chargeefficiency", Reason: "must be in (0, 1]"}
+	}
+
+	if c.ESS.DischargeEfficiency <= 0 || c.ESS.DischargeEfficiency > 1 {
+		return ErrInvalidConfig{Field: "ess.dischargeefficiency", Reason: "must be in (0, 1]"}
+	}
+
+	if c.ESS.MaxDurationHours > 0 && c.ESS.Capacity > c.ESS.PmaxDisch*c.ESS.MaxDurationHours {
+		return ErrInvalidConfig{Field: "ess.capacity", Reason: "must not exceed pmaxdisch * maxdurationhours"}
+	}
+
+	if (c.Strategy == "tariff" || c.Strategy == "mpc") && c.Tariff.Provider == "" {
+		return ErrInvalidConfig{Field: "tariff.provider", Reason: "must be set when strategy is \"tariff\" or \"mpc\""}
+	}
+
+	if c.Strategy == "mpc" && c.MPC.Horizon <= 0 {
+		return ErrInvalidConfig{Field: "mpc.horizon", Reason: "must be set when strategy is \"mpc\""}
+	}
+
+	tariff, err := c.Tariff.Build()
+	if err != nil {
+		return err
+	}
+	c.tariff = tariff
+
+	return nil
 }
 
+// BuiltTariff returns the Tariff built by the most recent successful Validate call (via
+// Populate). It must not be called before Populate.
+func (c config) BuiltTariff() Tariff {
+	return c.tariff
+}
+
+// defaultSOCLow and defaultSOCHigh are applied when a config leaves both soclow and sochigh at
+// their Go zero value, i.e. a config written before those fields existed. Defaulting only one of
+// them would still leave the SOC band degenerate (soclow > sochigh or an empty band), so both are
+// defaulted together, the same way MPCConfig.Build defaults an unset Step.
+const (
+	defaultSOCLow  = 0.2
+	defaultSOCHigh = 0.8
+
+	// defaultChargeEfficiency and defaultDischargeEfficiency are applied when a config leaves
+	// the matching efficiency field at its Go zero value, i.e. a config written before these
+	// fields existed. Unlike soclow/sochigh they are independent: each is validated on its own,
+	// so each is defaulted on its own.
+	defaultChargeEfficiency    = 0.95
+	defaultDischargeEfficiency = 0.95
+)
+
 // Populate populates config object reading file and env.
 func (c *config) Populate(ctx context.Context, filename string) error {
-	return cleanenv.ReadConfig(filename, c)
+	if err := cleanenv.ReadConfig(filename, c); err != nil {
+		return err
+	}
+
+	// soclow == sochigh == 0 is indistinguishable from "not set in this config", and would
+	// otherwise make ModeCharging practically unreachable (SOC <= 0) while ModeDischarging
+	// stays always allowed (SOC >= 0) - a silent behavioral regression for every config
+	// written before these fields existed.
+	if c.ESS.SOCLow == 0 && c.ESS.SOCHigh == 0 {
+		c.ESS.SOCLow = defaultSOCLow
+		c.ESS.SOCHigh = defaultSOCHigh
+	}
+
+	// Same reasoning as soclow/sochigh above: these are new fields that Validate rejects
+	// outright at the zero value, which would otherwise break every config written before they
+	// existed.
+	if c.ESS.ChargeEfficiency == 0 {
+		c.ESS.ChargeEfficiency = defaultChargeEfficiency
+	}
+
+	if c.ESS.DischargeEfficiency == 0 {
+		c.ESS.DischargeEfficiency = defaultDischargeEfficiency
+	}
+
+	return c.Validate()
 }
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestPopulateDefaultsPreExistingFields covers configs written before soclow/sochigh and
+// chargeefficiency/dischargeefficiency existed: Populate must default them rather than leave
+// Validate to reject the file outright.
+func TestPopulateDefaultsPreExistingFields(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+
+	const body = `{"ess":{"pmaxch":-5,"pmaxdisch":5,"capacity":10}}`
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	c := config{}
+	if err := c.Populate(context.Background(), path); err != nil {
+		t.Fatalf("Populate: %v", err)
+	}
+
+	if c.ESS.SOCLow != defaultSOCLow || c.ESS.SOCHigh != defaultSOCHigh {
+		t.Errorf("SOCLow/SOCHigh = %v/%v, want %v/%v", c.ESS.SOCLow, c.ESS.SOCHigh, defaultSOCLow, defaultSOCHigh)
+	}
+
+	if c.ESS.ChargeEfficiency != defaultChargeEfficiency {
+		t.Errorf("ChargeEfficiency = %v, want %v", c.ESS.ChargeEfficiency, defaultChargeEfficiency)
+	}
+
+	if c.ESS.DischargeEfficiency != defaultDischargeEfficiency {
+		t.Errorf("DischargeEfficiency = %v, want %v", c.ESS.DischargeEfficiency, defaultDischargeEfficiency)
+	}
+}
+
+// TestPopulateKeepsExplicitEfficiency covers the complementary case: a config that does set
+// chargeefficiency/dischargeefficiency must not have them overwritten by the default.
+func TestPopulateKeepsExplicitEfficiency(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+
+	const body = `{"ess":{"pmaxch":-5,"pmaxdisch":5,"capacity":10,"chargeefficiency":0.8,"dischargeefficiency":0.7}}`
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	c := config{}
+	if err := c.Populate(context.Background(), path); err != nil {
+		t.Fatalf("Populate: %v", err)
+	}
+
+	if c.ESS.ChargeEfficiency != 0.8 {
+		t.Errorf("ChargeEfficiency = %v, want 0.8", c.ESS.ChargeEfficiency)
+	}
+
+	if c.ESS.DischargeEfficiency != 0.7 {
+		t.Errorf("DischargeEfficiency = %v, want 0.7", c.ESS.DischargeEfficiency)
+	}
+}
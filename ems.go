@@ -3,7 +3,9 @@ package main
 import (
 	"context"
 	"fmt"
+	"math"
 	"math/rand"
+	"sync"
 	"time"
 
 	"github.com/rs/zerolog/log"
@@ -22,15 +24,65 @@ type EMS struct {
 	POC POC
 
 	PMaxSite float64
+
+	Strategy DispatchStrategy // dispatch heuristic used by BalanceEnergy, defaults to StrategyThreshold
+	Tariff   Tariff           // price signal used by StrategyTariff and StrategyMPC, nil otherwise
+	MPC      *MPCController   // receding-horizon planner used by StrategyMPC, nil otherwise
+
+	metrics EnergyMetrics // accumulated by integrateMetrics each tick, exposed via Metrics()
+
+	// mu guards ESS, PV, POC and metrics: Serve mutates them every tick while the HTTP
+	// control API in api.go reads/writes them concurrently from handler goroutines.
+	mu sync.Mutex
 }
 
-func (ems EMS) String() string {
+// String assumes the caller already holds ems.mu: Serve's tick does (it is itself printed from
+// there), and external callers such as the HTTP control API lock it explicitly beforehand.
+func (ems *EMS) String() string {
 	return fmt.Sprintf("EMS:\n\tess:%v\n\tpv:%v\n\tpoc:%v\n\tpmaxsite:%v\n", ems.ESS, ems.PV, ems.POC, ems.PMaxSite)
 }
 
-// Next for debugging purposes simulates a next step in decision loop
-func (ems *EMS) Next() {
-	ems.ESS.E -= ems.ESS.P
+// DispatchStrategy selects which heuristic EMS.Serve uses to decide ESS charge/discharge bias.
+type DispatchStrategy int
+
+const (
+	// StrategyThreshold is the original poc-percentage/SOC heuristic in ESS.BalanceEnergy.
+	StrategyThreshold DispatchStrategy = iota
+	// StrategyTariff uses ESS.BalanceEnergyTariff and requires EMS.Tariff to be set.
+	StrategyTariff
+	// StrategyMPC uses EMS.MPC and requires both EMS.MPC and EMS.Tariff to be set; it falls
+	// back to StrategyTariff, then StrategyThreshold, when the planner has nothing to work with.
+	StrategyMPC
+)
+
+func (s DispatchStrategy) String() string {
+	switch s {
+	case StrategyTariff:
+		return "tariff"
+	case StrategyMPC:
+		return "mpc"
+	default:
+		return "threshold"
+	}
+}
+
+// Next for debugging purposes simulates a next step in decision loop, dt elapsed since the
+// last call.
+func (ems *EMS) Next(dt time.Duration) {
+	hours := dt.Hours()
+
+	// Stored energy decays by a fixed fraction per hour, charging or not.
+	ems.ESS.E -= ems.ESS.E * (1 - math.Exp(-ems.ESS.SelfDischargeRate*hours))
+
+	// Apply the previous tick's power to stored energy through the round-trip efficiency:
+	// charging stores less than what was drawn, discharging draws down more than what was
+	// delivered.
+	if ems.ESS.P < 0 {
+		ems.ESS.E += -ems.ESS.P * hours * ems.ESS.ChargeEfficiency
+	} else if ems.ESS.P > 0 {
+		ems.ESS.E -= ems.ESS.P * hours / ems.ESS.DischargeEfficiency
+	}
+
 	ems.ESS.P = ems.ESS.SetPointP
 
 	ems.PV.P = ems.PV.SetPointP
@@ -44,12 +96,36 @@ func (ems *EMS) Next() {
 	ems.POC.P = -ems.PV.P - ems.ESS.P + (float64(rand.Int63n(10)+10) / 100 * ems.PMaxSite)
 }
 
-func (ems EMS) GetPLoad() float64 {
+// EffectiveBatteryACPower returns how much of battery (a magnitude, e.g. ESS charge/discharge
+// power) actually crosses the shared AC inverter, given the AC power grid already drawn by PV
+// and the inverter's maxGrid (InverterACRating) rating. PV DC production charging the battery
+// beyond that headroom is DC-coupled and never reaches the AC side, so it must not be counted
+// as available to offset AC load.
+func EffectiveBatteryACPower(grid float64, battery float64, maxGrid float64) float64 {
+	headroom := maxGrid - grid
+	if headroom < 0 {
+		headroom = 0
+	}
+
+	if battery > headroom {
+		return headroom
+	}
+
+	return battery
+}
+
+// GetPLoad assumes the caller already holds ems.mu (Serve's tick and integrateMetrics do).
+func (ems *EMS) GetPLoad() float64 {
 	// TODO: Ensure those 3 variables are returned at same timestamp to guarantee validity.
-	return ems.POC.P - ems.PV.P - ems.ESS.P
+	essAC := ems.ESS.P
+	if essAC < 0 {
+		essAC = -EffectiveBatteryACPower(ems.PV.P, -essAC, ems.PV.InverterACRating)
+	}
+
+	return ems.POC.P - ems.PV.P - essAC
 }
 
-func (ems EMS) Serve(ctx context.Context, delay time.Duration) error {
+func (ems *EMS) Serve(ctx context.Context, delay time.Duration) error {
 	// Adjust margins
 	margin := 0.1 // 10% margin for safety triggers
 	ems.PMaxSite = ems.PMaxSite - (ems.PMaxSite * margin)
@@ -69,75 +145,127 @@ func (ems EMS) Serve(ctx context.Context, delay time.Duration) error {
 		default:
 		}
 
-		// DEBUG: simulate next iteration
-		ems.Next()
-		fmt.Println(ems)
+		// The whole tick runs under ems.mu: Serve is the only writer of ESS/PV/POC/metrics on
+		// its own, but the HTTP control API (api.go) reads and writes the same fields from
+		// handler goroutines, so the tick must be atomic with respect to it.
+		func() {
+			ems.mu.Lock()
+			defer ems.mu.Unlock()
 
-		/*
-		 DOMAIN LOGIC
-		*/
+			// DEBUG: simulate next iteration
+			ems.Next(delay)
+			fmt.Println(ems)
 
-		poc := ems.POC.GetMeterMeasure()
-		// fmt.Printf("poc: %v\nems:%v\n", poc, ems)
+			/*
+			 DOMAIN LOGIC
+			*/
 
-		// WARNING: Consumption exceeds PmaxSite
-		// CHANGE POC
-		if poc > ems.PMaxSite {
-			if err := ems.IncreaseSiteDischarge(poc - ems.PMaxSite); err != nil {
-				log.Error().Err(err).Msg("failed to increase site discharge")
+			now := time.Now()
 
-				continue
+			poc := ems.POC.GetMeterMeasure()
+			// fmt.Printf("poc: %v\nems:%v\n", poc, ems)
+
+			// WARNING: Consumption exceeds PmaxSite
+			// CHANGE POC
+			if poc > ems.PMaxSite {
+				if err := ems.IncreaseSiteDischarge(poc-ems.PMaxSite, now); err != nil {
+					log.Error().Err(err).Msg("failed to increase site discharge")
+
+					return
+				}
+
+				log.Info().Msg("increased site discharge")
+				return
 			}
 
-			log.Info().Msg("increased site discharge")
-			continue
-		}
+			// WARNING: Consumption is below pMinSite
+			// CHANGE POC
+			if poc < pMinSite {
+				if err := ems.DecreaseSiteDischarge(poc-pMinSite, now); err != nil {
+					log.Error().Err(err).Msg("failed to decrease site discharge")
 
-		// WARNING: Consumption is below pMinSite
-		// CHANGE POC
-		if poc < pMinSite {
-			if err := ems.DecreaseSiteDischarge(poc - pMinSite); err != nil {
-				log.Error().Err(err).Msg("failed to decrease site discharge")
+					return
+				}
 
-				continue
+				log.Info().Msg("decreased site discharge")
+				return
 			}
 
-			log.Info().Msg("decreased site discharge")
-			continue
-		}
+			// Balance PV and ESS productions
+			// KEEP POC
+			if err := ems.BalanceSiteDischarge(poc, now); err != nil {
+				log.Error().Err(err).Msg("failed to balance site discharge")
 
-		// Balance PV and ESS productions
-		// KEEP POC
-		if err := ems.BalanceSiteDischarge(poc); err != nil {
-			log.Error().Err(err).Msg("failed to balance site discharge")
+				return
+			}
+			log.Info().Msg("balanced site discharge")
 
-			continue
-		}
-		log.Info().Msg("balanced site discharge")
+			// Adjust PV charge (and POC) depending on current stored energy and poc %
+			// CHANGE POC
+			if pvDelta, err := ems.PV.BalanceEnergy(poc, ems.PMaxSite); err != nil {
+				log.Error().Err(err).Msg("failed to balance pv energy")
 
-		// Adjust PV charge (and POC) depending on current stored energy and poc %
-		// CHANGE POC
-		if delta, err := ems.PV.BalanceEnergy(poc, ems.PMaxSite); err != nil {
-			log.Error().Err(err).Msg("failed to balance pv energy")
+				return
+			} else {
+				poc += pvDelta
+			}
+			log.Info().Msg("balanced pv energy")
+
+			// Adjust ESS charge (and POC) depending on current stored energy and poc %, on the
+			// tariff price signal when StrategyTariff is selected, or on the receding-horizon
+			// plan when StrategyMPC is selected. An operator-forced boost via the HTTP control
+			// API overrides all three until it expires.
+			// CHANGE POC
+			var (
+				delta      float64
+				transition Transition
+				err        error
+			)
+
+			switch {
+			case now.Before(ems.ESS.BoostUntil):
+				delta, transition, err = ems.ESS.applyBoost(poc, ems.PMaxSite, now)
+			case ems.Strategy == StrategyMPC && ems.MPC != nil && ems.Tariff != nil:
+				mpcDelta, ok, mpcErr := ems.MPC.Dispatch(ctx, ems.ESS, ems.PMaxSite, ems.Tariff, now)
+
+				switch {
+				case mpcErr != nil || !ok:
+					// The solver failed (e.g. no forecaster/rates configured), or had
+					// nothing usable to plan from this tick: either way the tariff
+					// heuristic is the fallback, per the request.
+					delta, transition, err = ems.ESS.BalanceEnergyTariff(poc, ems.PMaxSite, ems.Tariff, now)
+				case mpcDelta != 0:
+					delta = mpcDelta
+					transition = ems.ESS.setMode(modeOf(delta), now)
+					_, err = ems.ESS.AdjustDischarge(delta, now)
+				}
+			case ems.Strategy == StrategyTariff && ems.Tariff != nil:
+				delta, transition, err = ems.ESS.BalanceEnergyTariff(poc, ems.PMaxSite, ems.Tariff, now)
+			default:
+				delta, transition, err = ems.ESS.BalanceEnergy(poc, ems.PMaxSite, now)
+			}
 
-			continue
-		} else {
-			poc += delta
-		}
-		log.Info().Msg("balanced pv energy")
+			if err != nil {
+				log.Error().Err(err).Msg("failed to balance ess energy")
 
-		// Adjust ESS charge (and POC) depending on current stored energy and poc %
-		// CHANGE POC
-		if delta, err := ems.ESS.BalanceEnergy(poc, ems.PMaxSite); err != nil {
-			log.Error().Err(err).Msg("failed to balance ess energy")
+				return
+			}
 
-			continue
-		} else {
 			poc += delta
-		}
-		log.Info().Msg("balanced ess energy")
+			if transition.Changed {
+				log.Info().Stringer("from", transition.From).Stringer("to", transition.To).Msg("ess mode transition")
+			}
+			log.Info().Msg("balanced ess energy")
 
-		log.Info().Msg("ems decision done")
+			ems.integrateMetrics(poc, delay, now)
+			log.Info().
+				Float64("selfconsumption", ems.metrics.SelfConsumptionRatio()).
+				Float64("autarky", ems.metrics.AutarkyRatio()).
+				Float64("savings", ems.metrics.Savings()).
+				Msg("energy metrics")
+
+			log.Info().Msg("ems decision done")
+		}()
 	}
 
 	return nil
@@ -145,7 +273,7 @@ func (ems EMS) Serve(ctx context.Context, delay time.Duration) error {
 
 // BalanceSiteDischarge balances site discharge by adjusting ESS and PV productions.
 // It keeps same POC value.
-func (ems *EMS) BalanceSiteDischarge(poc float64) error {
+func (ems *EMS) BalanceSiteDischarge(poc float64, now time.Time) error {
 	// We try to maximize PV discharge
 	available := ems.PV.AvailableProd()
 
@@ -157,22 +285,26 @@ func (ems *EMS) BalanceSiteDischarge(poc float64) error {
 		if available > p {
 			// We have more PV discharge than ESS discharge
 			ems.PV.AdjustDischarge(p)
-			ems.ESS.AdjustDischarge(-p)
+			ems.ESS.AdjustDischarge(-p, now)
 		} else {
 			ems.PV.AdjustDischarge(available)
-			ems.ESS.AdjustDischarge(-available) // TODO: potentially check result is 0 ?
+			ems.ESS.AdjustDischarge(-available, now) // TODO: potentially check result is 0 ?
 		}
 	} else if available > 0 && p < 0 {
 		// PV is discharging but ESS is charging
 		// maxChAvailable is the positive diff between ESS max charge and current charge
 		maxChAvailable := p - pMaxCh
 
+		// Clamp to the inverter AC headroom: charging beyond InverterACRating is DC-coupled
+		// and never reaches the AC side, so it must not be double-counted as available here.
+		maxChAvailable = EffectiveBatteryACPower(ems.PV.P, maxChAvailable, ems.PV.InverterACRating)
+
 		if available > maxChAvailable {
 			ems.PV.AdjustDischarge(maxChAvailable)
-			ems.ESS.AdjustDischarge(-maxChAvailable)
+			ems.ESS.AdjustDischarge(-maxChAvailable, now)
 		} else {
 			ems.PV.AdjustDischarge(available)
-			ems.ESS.AdjustDischarge(-available) // TODO: potentially check result is 0 ?
+			ems.ESS.AdjustDischarge(-available, now) // TODO: potentially check result is 0 ?
 		}
 	}
 
@@ -180,7 +312,7 @@ func (ems *EMS) BalanceSiteDischarge(poc float64) error {
 }
 
 // IncreaseSiteDischarge handles external discharge by utilizing the grid.
-func (ems *EMS) IncreaseSiteDischarge(discharge float64) error {
+func (ems *EMS) IncreaseSiteDischarge(discharge float64, now time.Time) error {
 	// Prioritize PV discharge
 	discharge = ems.PV.AdjustDischarge(discharge)
 	if discharge == 0 {
@@ -189,7 +321,7 @@ func (ems *EMS) IncreaseSiteDischarge(discharge float64) error {
 
 	// Use ESS to cover remaining discharge
 	var err error
-	discharge, err = ems.ESS.AdjustDischarge(discharge)
+	discharge, err = ems.ESS.AdjustDischarge(discharge, now)
 	if err != nil {
 		return err
 	}
@@ -201,9 +333,9 @@ func (ems *EMS) IncreaseSiteDischarge(discharge float64) error {
 	return nil
 }
 
-func (ems *EMS) DecreaseSiteDischarge(discharge float64) error {
+func (ems *EMS) DecreaseSiteDischarge(discharge float64, now time.Time) error {
 	// Prioritize ESS discharge/charge
-	discharge, err := ems.ESS.AdjustDischarge(discharge)
+	discharge, err := ems.ESS.AdjustDischarge(discharge, now)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,43 @@
+package main
+
+import "testing"
+
+func TestEffectiveBatteryACPower(t *testing.T) {
+	tests := []struct {
+		name                   string
+		grid, battery, maxGrid float64
+		want                   float64
+	}{
+		{"battery fits within headroom", 2, 3, 10, 3},
+		{"DC excess over 50W clipped to headroom", 8, 5, 10, 2}, // only 2kW of headroom: the 3kW DC-coupled excess never reaches the AC side
+		{"grid already at cap: all charging is DC-coupled", 10, 5, 10, 0},
+		{"grid above cap: headroom clamped to zero", 12, 5, 10, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EffectiveBatteryACPower(tt.grid, tt.battery, tt.maxGrid); got != tt.want {
+				t.Errorf("EffectiveBatteryACPower(%v, %v, %v) = %v, want %v", tt.grid, tt.battery, tt.maxGrid, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestEMSGetPLoadClampsDCCoupledExcess covers the bug GetPLoad used to have: PV DC production
+// charging the battery beyond the inverter's AC rating never reaches the AC side, so it must
+// not be subtracted from load as if it had.
+func TestEMSGetPLoadClampsDCCoupledExcess(t *testing.T) {
+	ems := EMS{
+		ESS: ESS{P: -5}, // charging at 5kW
+		PV:  PV{P: 3, InverterACRating: 6},
+		POC: POC{P: -2},
+	}
+
+	// headroom = InverterACRating - PV.P = 3kW, so only 3kW of the 5kW charge is AC-coupled;
+	// the remaining 2kW DC excess (well over the 50W the request calls out) must not offset load.
+	want := -2.0 - 3 - (-3)
+
+	if got := ems.GetPLoad(); got != want {
+		t.Errorf("GetPLoad() = %v, want %v", got, want)
+	}
+}
@@ -1,6 +1,9 @@
 package main
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 type ErrESSEmpty struct {
 	Required float64
@@ -17,3 +20,43 @@ type ErrGridMissingCoverage struct {
 func (err ErrGridMissingCoverage) Error() string {
 	return fmt.Sprintf("Grid missing coverage, required %f kWh", err.Required)
 }
+
+type ErrInvalidConfig struct {
+	Field  string
+	Reason string
+}
+
+func (err ErrInvalidConfig) Error() string {
+	return fmt.Sprintf("invalid config field %s: %s", err.Field, err.Reason)
+}
+
+type ErrTariffNoRate struct {
+	At time.Time
+}
+
+func (err ErrTariffNoRate) Error() string {
+	return fmt.Sprintf("tariff has no rate covering %v", err.At)
+}
+
+type ErrTariffUnsupported struct {
+	Op string
+}
+
+func (err ErrTariffUnsupported) Error() string {
+	return fmt.Sprintf("tariff operation %s is not supported by this provider", err.Op)
+}
+
+type ErrForecastUnavailable struct{}
+
+func (err ErrForecastUnavailable) Error() string {
+	return "forecast unavailable"
+}
+
+type ErrTariffFetch struct {
+	URL    string
+	Status int
+}
+
+func (err ErrTariffFetch) Error() string {
+	return fmt.Sprintf("fetching tariff schedule from %s: unexpected status %d", err.URL, err.Status)
+}
@@ -1,6 +1,52 @@
 package main
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
+
+// Mode is the current direction the ESS is committed to for the running dwell window.
+// It behaves like a binary indicator: within one dwell window the ESS may only move in
+// one direction (Charging or Discharging), preventing the oscillation that occurs when
+// AdjustDischarge is allowed to flip sign every tick.
+type Mode int
+
+const (
+	ModeIdle Mode = iota
+	ModeCharging
+	ModeDischarging
+)
+
+func (m Mode) String() string {
+	switch m {
+	case ModeCharging:
+		return "charging"
+	case ModeDischarging:
+		return "discharging"
+	default:
+		return "idle"
+	}
+}
+
+// modeOf returns the Mode a power delta commits the ESS to: negative charges, positive
+// discharges, zero leaves it idle.
+func modeOf(delta float64) Mode {
+	switch {
+	case delta < 0:
+		return ModeCharging
+	case delta > 0:
+		return ModeDischarging
+	default:
+		return ModeIdle
+	}
+}
+
+// Transition reports whether BalanceEnergy changed the ESS mode and, if so, between what.
+type Transition struct {
+	Changed bool
+	From    Mode
+	To      Mode
+}
 
 // ESS is an Energy Storage System (ESS, e.g. a battery) of capacity ess_capacity in kWh.
 type ESS struct {
@@ -13,10 +59,69 @@ type ESS struct {
 	Capacity float64 `json:"capacity"` // capacity in kWh
 
 	SetPointP float64 `json:"setpointp"` // active power setpoint computed by the EMS in kW (AC side, <0 for charge setpoint, >0 for discharge setpoint)
+
+	Mode      Mode          `json:"mode"`      // current commitment direction, held until DwellTime elapses
+	ModeSince time.Time     `json:"-"`         // timestamp of the last mode transition
+	DwellTime time.Duration `json:"dwelltime"` // minimum time Mode is held before it is allowed to flip
+	SOCLow    float64       `json:"soclow"`    // SOC fraction below which a transition to Charging is allowed
+	SOCHigh   float64       `json:"sochigh"`   // SOC fraction above which a transition to Discharging is allowed
+
+	SelfDischargeRate   float64 `json:"selfdischargerate"`   // fraction of stored energy lost per hour, idle or not
+	ChargeEfficiency    float64 `json:"chargeefficiency"`    // fraction of charge power actually stored, in (0, 1]
+	DischargeEfficiency float64 `json:"dischargeefficiency"` // fraction of stored energy actually delivered on discharge, in (0, 1]
+	MaxDurationHours    float64 `json:"maxdurationhours"`    // longest duration at PmaxDisch the chemistry supports; constrains Capacity <= PmaxDisch * MaxDurationHours
+
+	BoostMode  Mode      `json:"-"` // mode forced by the HTTP control API, ignored once BoostUntil has elapsed
+	BoostPower float64   `json:"-"` // magnitude of the forced power, clamped to PmaxCh/PmaxDisch
+	BoostUntil time.Time `json:"-"` // boost expiry; Serve falls back to BalanceEnergy once past it
 }
 
 func (ess ESS) String() string {
-	return fmt.Sprintf("P: %.2f, PmaxCh: %.2f, PmaxDisch: %.2f, E: %.2f, Capacity: %.2f, SetPointP: %.2f\n", ess.P, ess.PmaxCh, ess.PmaxDisch, ess.E, ess.Capacity, ess.SetPointP)
+	return fmt.Sprintf("P: %.2f, PmaxCh: %.2f, PmaxDisch: %.2f, E: %.2f, Capacity: %.2f, SetPointP: %.2f, Mode: %v, SelfDischargeRate: %.4f, ChargeEfficiency: %.2f, DischargeEfficiency: %.2f\n",
+		ess.P, ess.PmaxCh, ess.PmaxDisch, ess.E, ess.Capacity, ess.SetPointP, ess.Mode, ess.SelfDischargeRate, ess.ChargeEfficiency, ess.DischargeEfficiency)
+}
+
+// SOC returns the current state of charge as a fraction of Capacity.
+func (ess ESS) SOC() float64 {
+	if ess.Capacity == 0 {
+		return 0
+	}
+
+	return ess.E / ess.Capacity
+}
+
+// canTransitionTo reports whether ess.Mode is allowed to move to target at now, i.e. the
+// dwell window has elapsed and the SOC band for the target direction is reached.
+func (ess ESS) canTransitionTo(target Mode, now time.Time) bool {
+	if ess.Mode == target {
+		return true
+	}
+
+	if now.Sub(ess.ModeSince) < ess.DwellTime {
+		return false
+	}
+
+	switch target {
+	case ModeCharging:
+		return ess.SOC() <= ess.SOCLow
+	case ModeDischarging:
+		return ess.SOC() >= ess.SOCHigh
+	default:
+		return true
+	}
+}
+
+// setMode commits ess to target, recording the transition time when it actually changes.
+func (ess *ESS) setMode(target Mode, now time.Time) Transition {
+	if ess.Mode == target {
+		return Transition{}
+	}
+
+	from := ess.Mode
+	ess.Mode = target
+	ess.ModeSince = now
+
+	return Transition{Changed: true, From: from, To: target}
 }
 
 // GetMeasure() returns Pess, Pmaxch, Pmaxdisch, Eess
@@ -30,11 +135,26 @@ func (ess *ESS) SetSetpoint(setpointPEss float64) {
 	ess.SetPointP = setpointPEss
 }
 
-func (ess *ESS) AdjustDischarge(discharge float64) (float64, error) {
+// AdjustDischarge adjusts ESS discharge, gated by now against the current Mode: it refuses
+// to flip the ESS into the opposite direction until canTransitionTo allows it, returning the
+// full discharge as uncovered in that case so the caller (grid/PV) picks up the slack.
+func (ess *ESS) AdjustDischarge(discharge float64, now time.Time) (float64, error) {
 	if discharge < 0 {
+		if !ess.canTransitionTo(ModeCharging, now) {
+			return discharge, nil
+		}
+
+		ess.setMode(ModeCharging, now)
+
 		return ess.DecreaseDischarge(discharge)
 	}
 
+	if !ess.canTransitionTo(ModeDischarging, now) {
+		return discharge, nil
+	}
+
+	ess.setMode(ModeDischarging, now)
+
 	return ess.IncreaseDischarge(discharge)
 }
 
@@ -102,8 +222,10 @@ func (ess *ESS) DecreaseDischarge(discharge float64) (float64, error) {
 	return discharge - (maxCh - p), nil
 }
 
-// BalanceEnergy balances the energy in the ESS by adjusting the charge/discharge and potentially modifying POC.
-func (ess *ESS) BalanceEnergy(poc float64, pocMax float64) (float64, error) {
+// BalanceEnergy balances the energy in the ESS by adjusting the charge/discharge and potentially
+// modifying POC. It respects the current Mode: while the dwell window holds, it will only ever
+// move P further in the committed direction, never flip sign.
+func (ess *ESS) BalanceEnergy(poc float64, pocMax float64, now time.Time) (float64, Transition, error) {
 	pocPercentage := poc / pocMax
 	ePercentage := ess.E / ess.Capacity
 
@@ -112,25 +234,74 @@ func (ess *ESS) BalanceEnergy(poc float64, pocMax float64) (float64, error) {
 	// If consumption is low and ESS is low energy, slowly modify charge/discharge
 	// TODO: use some formula instead ?
 	if pocPercentage < 0.3 && ePercentage < 0.7 && ess.P > ess.PmaxCh {
+		if !ess.canTransitionTo(ModeCharging, now) {
+			return 0, Transition{}, nil
+		}
+
+		transition := ess.setMode(ModeCharging, now)
+
 		// delta ensures this modification is not too big for global POC
 		delta := max(-ess.PmaxCh/20, -pocMax/20)
-		ess.AdjustDischarge(delta)
+		ess.AdjustDischarge(delta, now)
 
-		return delta, nil
+		return delta, transition, nil
 	}
 
 	// If consumption is high and ESS is high energy, slowly modify charge/discharge
 	// TODO: use some formula instead ?
 	if pocPercentage > 0.7 && ePercentage > 0.7 && ess.P < ess.PmaxDisch {
+		if !ess.canTransitionTo(ModeDischarging, now) {
+			return 0, Transition{}, nil
+		}
+
+		transition := ess.setMode(ModeDischarging, now)
+
 		// delta ensures this modification is not too big for global POC
 		delta := min(ess.PmaxDisch/20, pocMax/20)
-		ess.AdjustDischarge(delta)
+		ess.AdjustDischarge(delta, now)
 
-		return delta, nil
+		return delta, transition, nil
 	}
 
 	// If consumption is high and ESS is low energy, do nothing
 	// If consumption is low and ESS is high energy, do nothing
 
-	return 0, nil
+	return 0, Transition{}, nil
+}
+
+// applyBoost forces the ESS into BoostMode at up to BoostPower, overriding the SOC-band
+// heuristic in BalanceEnergy/BalanceEnergyTariff for as long as Serve sees now before
+// BoostUntil. It still respects PmaxCh/PmaxDisch, and clamps so that poc+delta does not cross
+// pocMax (PMaxSite).
+func (ess *ESS) applyBoost(poc float64, pocMax float64, now time.Time) (float64, Transition, error) {
+	var (
+		target Mode
+		limit  float64
+	)
+
+	switch ess.BoostMode {
+	case ModeCharging:
+		target = ModeCharging
+		limit = max(-ess.BoostPower, ess.PmaxCh)
+	case ModeDischarging:
+		target = ModeDischarging
+		limit = min(ess.BoostPower, ess.PmaxDisch)
+	default:
+		return 0, Transition{}, nil
+	}
+
+	// A boost is an explicit operator command: it forces the mode regardless of dwell time
+	// or SOC band.
+	transition := ess.setMode(target, now)
+
+	delta := limit - ess.P
+	if poc+delta < pocMax {
+		delta = pocMax - poc
+	}
+
+	if _, err := ess.AdjustDischarge(delta, now); err != nil {
+		return 0, transition, err
+	}
+
+	return delta, transition, nil
 }
@@ -27,23 +27,50 @@ func run(prog string, filename string) {
 		return
 	}
 
+	tariff := cfg.BuiltTariff()
+
+	strategy := StrategyThreshold
+	switch cfg.Strategy {
+	case "tariff":
+		strategy = StrategyTariff
+	case "mpc":
+		strategy = StrategyMPC
+	}
+
 	ems := EMS{
 		ESS: ESS{
-			P:         cfg.ESS.P,
-			PmaxCh:    cfg.ESS.PmaxCh,
-			PmaxDisch: cfg.ESS.PmaxDisch,
-			E:         cfg.ESS.E,
-			Capacity:  cfg.ESS.Capacity,
+			P:                   cfg.ESS.P,
+			PmaxCh:              cfg.ESS.PmaxCh,
+			PmaxDisch:           cfg.ESS.PmaxDisch,
+			E:                   cfg.ESS.E,
+			Capacity:            cfg.ESS.Capacity,
+			DwellTime:           cfg.ESS.DwellTime,
+			SOCLow:              cfg.ESS.SOCLow,
+			SOCHigh:             cfg.ESS.SOCHigh,
+			SelfDischargeRate:   cfg.ESS.SelfDischargeRate,
+			ChargeEfficiency:    cfg.ESS.ChargeEfficiency,
+			DischargeEfficiency: cfg.ESS.DischargeEfficiency,
+			MaxDurationHours:    cfg.ESS.MaxDurationHours,
 		},
 		PV: PV{
-			P:     cfg.PV.P,
-			Pprod: cfg.PV.Pprod,
-			Peak:  cfg.PV.Peak,
+			P:                cfg.PV.P,
+			Pprod:            cfg.PV.Pprod,
+			Peak:             cfg.PV.Peak,
+			InverterACRating: cfg.PV.InverterACRating,
 		},
 		POC: POC{
 			P: cfg.POC.P,
 		},
 		PMaxSite: cfg.PMaxSite,
+		Strategy: strategy,
+		Tariff:   tariff,
+		MPC:      cfg.MPC.Build(),
+	}
+
+	if cfg.MetricsSnapshotPath != "" {
+		if err := ems.LoadMetricsSnapshot(cfg.MetricsSnapshotPath); err != nil {
+			log.Error().Err(err).Msg("failed to load metrics snapshot")
+		}
 	}
 
 	go func() {
@@ -52,6 +79,16 @@ func run(prog string, filename string) {
 		}
 	}()
 
+	if cfg.APIAddr != "" {
+		api := &API{EMS: &ems}
+
+		go func() {
+			if err := api.ListenAndServe(ctx, cfg.APIAddr); err != nil {
+				log.Error().Err(err).Msg("failed to serve api")
+			}
+		}()
+	}
+
 	log.Info().Msg("ems up")
 
 	// listen for signals
@@ -65,6 +102,12 @@ func run(prog string, filename string) {
 		case syscall.SIGINT:
 			fallthrough
 		case syscall.SIGTERM:
+			if cfg.MetricsSnapshotPath != "" {
+				if err := ems.SaveMetricsSnapshot(cfg.MetricsSnapshotPath); err != nil {
+					log.Error().Err(err).Msg("failed to save metrics snapshot")
+				}
+			}
+
 			fmt.Println("successfully closed ems")
 
 			return
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+)
+
+// EnergyMetrics accumulates per-tick energy integrations and derives the headline KPIs: solar
+// share of consumption, grid dependence, and, when a Tariff is configured, cumulative cost and
+// savings vs. a "no ESS, no PV" baseline.
+type EnergyMetrics struct {
+	SolarKWh      float64 `json:"solarkwh"`      // cumulative PV power delivered on site
+	GridKWh       float64 `json:"gridkwh"`       // cumulative grid import
+	PVProducedKWh float64 `json:"pvproducedkwh"` // cumulative PV production, used or not
+	LoadKWh       float64 `json:"loadkwh"`       // cumulative site load
+
+	CumulativeCost float64 `json:"cumulativecost"` // requires Tariff: cost of actual grid import
+	NaiveCost      float64 `json:"naivecost"`      // requires Tariff: cost of a "no ESS, no PV" baseline
+}
+
+// SelfConsumptionRatio is the fraction of PV production actually used on site rather than
+// curtailed.
+func (m EnergyMetrics) SelfConsumptionRatio() float64 {
+	if m.PVProducedKWh == 0 {
+		return 0
+	}
+
+	return m.SolarKWh / m.PVProducedKWh
+}
+
+// AutarkyRatio is the fraction of load served without importing from the grid.
+func (m EnergyMetrics) AutarkyRatio() float64 {
+	if m.LoadKWh == 0 {
+		return 0
+	}
+
+	return 1 - m.GridKWh/m.LoadKWh
+}
+
+// Savings is the cumulative cost saved vs. the naive baseline. Requires Tariff.
+func (m EnergyMetrics) Savings() float64 {
+	return m.NaiveCost - m.CumulativeCost
+}
+
+// Metrics returns the energy KPIs accumulated since EMS started (or since the last loaded
+// snapshot).
+func (ems *EMS) Metrics() EnergyMetrics {
+	ems.mu.Lock()
+	defer ems.mu.Unlock()
+
+	return ems.metrics
+}
+
+// integrateMetrics integrates this tick's power flows into metrics, dt elapsed since the
+// previous tick.
+func (ems *EMS) integrateMetrics(poc float64, dt time.Duration, now time.Time) {
+	hours := dt.Hours()
+
+	// GetPLoad follows poc's sign convention: negative for consumption.
+	load := -ems.GetPLoad()
+	if load < 0 {
+		load = 0
+	}
+
+	gridImport := 0.0
+	if poc < 0 {
+		gridImport = -poc
+	}
+
+	ems.metrics.LoadKWh += load * hours
+	ems.metrics.PVProducedKWh += ems.PV.Pprod * hours
+	ems.metrics.SolarKWh += ems.PV.P * hours
+	ems.metrics.GridKWh += gridImport * hours
+
+	if ems.Tariff == nil {
+		return
+	}
+
+	rate, err := ems.Tariff.Current(now)
+	if err != nil {
+		return
+	}
+
+	ems.metrics.CumulativeCost += rate.Price * gridImport * hours
+	ems.metrics.NaiveCost += rate.Price * load * hours
+}
+
+// SaveMetricsSnapshot persists the current metrics to path as JSON, so long-run KPIs survive a
+// restart.
+func (ems *EMS) SaveMetricsSnapshot(path string) error {
+	ems.mu.Lock()
+	data, err := json.Marshal(ems.metrics)
+	ems.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0o644)
+}
+
+// LoadMetricsSnapshot restores metrics previously saved by SaveMetricsSnapshot. A missing file
+// is not an error: it means there is nothing to resume from yet.
+func (ems *EMS) LoadMetricsSnapshot(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return err
+	}
+
+	ems.mu.Lock()
+	defer ems.mu.Unlock()
+
+	return json.Unmarshal(data, &ems.metrics)
+}
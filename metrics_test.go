@@ -0,0 +1,110 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEnergyMetricsRatios(t *testing.T) {
+	m := EnergyMetrics{
+		SolarKWh:      6,
+		PVProducedKWh: 10,
+		GridKWh:       3,
+		LoadKWh:       12,
+	}
+
+	if got := m.SelfConsumptionRatio(); got != 0.6 {
+		t.Errorf("SelfConsumptionRatio() = %v, want 0.6", got)
+	}
+
+	if got := m.AutarkyRatio(); got != 0.75 {
+		t.Errorf("AutarkyRatio() = %v, want 0.75", got)
+	}
+}
+
+func TestEnergyMetricsRatiosZeroDenominator(t *testing.T) {
+	var m EnergyMetrics
+
+	if got := m.SelfConsumptionRatio(); got != 0 {
+		t.Errorf("SelfConsumptionRatio() = %v, want 0", got)
+	}
+
+	if got := m.AutarkyRatio(); got != 0 {
+		t.Errorf("AutarkyRatio() = %v, want 0", got)
+	}
+}
+
+func TestEnergyMetricsSavings(t *testing.T) {
+	m := EnergyMetrics{NaiveCost: 10, CumulativeCost: 4}
+
+	if got := m.Savings(); got != 6 {
+		t.Errorf("Savings() = %v, want 6", got)
+	}
+}
+
+// TestSaveLoadMetricsSnapshotRoundTrip covers the restart-resume path: metrics saved by
+// SaveMetricsSnapshot must come back identical via LoadMetricsSnapshot.
+func TestSaveLoadMetricsSnapshotRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.json")
+
+	ems := &EMS{}
+	ems.metrics = EnergyMetrics{
+		SolarKWh:       1,
+		GridKWh:        2,
+		PVProducedKWh:  3,
+		LoadKWh:        4,
+		CumulativeCost: 5,
+		NaiveCost:      6,
+	}
+
+	if err := ems.SaveMetricsSnapshot(path); err != nil {
+		t.Fatalf("SaveMetricsSnapshot: %v", err)
+	}
+
+	restored := &EMS{}
+	if err := restored.LoadMetricsSnapshot(path); err != nil {
+		t.Fatalf("LoadMetricsSnapshot: %v", err)
+	}
+
+	if restored.metrics != ems.metrics {
+		t.Errorf("restored.metrics = %+v, want %+v", restored.metrics, ems.metrics)
+	}
+}
+
+// TestLoadMetricsSnapshotMissingFile covers the "nothing to resume from yet" case: a missing
+// file is not an error.
+func TestLoadMetricsSnapshotMissingFile(t *testing.T) {
+	ems := &EMS{}
+
+	if err := ems.LoadMetricsSnapshot(filepath.Join(t.TempDir(), "does-not-exist.json")); err != nil {
+		t.Errorf("LoadMetricsSnapshot: %v, want nil for a missing file", err)
+	}
+}
+
+// TestIntegrateMetricsAccumulates covers one tick's worth of accumulation: load/PV/grid energy
+// integrate over dt, and with no Tariff configured the cost fields stay at zero.
+func TestIntegrateMetricsAccumulates(t *testing.T) {
+	ems := &EMS{
+		PV:  PV{P: 2, Pprod: 3, InverterACRating: 10},
+		POC: POC{P: -1},
+	}
+
+	ems.integrateMetrics(-1, time.Hour, time.Now())
+
+	if ems.metrics.PVProducedKWh != 3 {
+		t.Errorf("PVProducedKWh = %v, want 3", ems.metrics.PVProducedKWh)
+	}
+
+	if ems.metrics.SolarKWh != 2 {
+		t.Errorf("SolarKWh = %v, want 2", ems.metrics.SolarKWh)
+	}
+
+	if ems.metrics.GridKWh != 1 {
+		t.Errorf("GridKWh = %v, want 1", ems.metrics.GridKWh)
+	}
+
+	if ems.metrics.CumulativeCost != 0 || ems.metrics.NaiveCost != 0 {
+		t.Errorf("CumulativeCost/NaiveCost = %v/%v, want 0/0 with no Tariff", ems.metrics.CumulativeCost, ems.metrics.NaiveCost)
+	}
+}
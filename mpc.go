@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// Forecaster predicts PV production and load over a horizon of hourly steps, so MPCController
+// can plan ahead instead of reacting tick by tick.
+type Forecaster interface {
+	// Forecast returns Pprod and Pload, one sample per step, for the horizon steps starting
+	// at from.
+	Forecast(ctx context.Context, from time.Time, horizon int) (pprod []float64, pload []float64, err error)
+}
+
+// PersistenceForecaster is the default Forecaster: it repeats yesterday's hourly Pprod/Pload
+// samples for the requested horizon, since that is the cheapest baseline available without a
+// dedicated weather/load forecasting pipeline.
+type PersistenceForecaster struct {
+	Pprod []float64 // yesterday's Pprod samples, one per hour of day
+	Pload []float64 // yesterday's Pload samples, one per hour of day
+}
+
+func (f PersistenceForecaster) Forecast(_ context.Context, from time.Time, horizon int) ([]float64, []float64, error) {
+	if len(f.Pprod) == 0 || len(f.Pload) == 0 {
+		return nil, nil, ErrForecastUnavailable{}
+	}
+
+	pprod := make([]float64, horizon)
+	pload := make([]float64, horizon)
+
+	for i := 0; i < horizon; i++ {
+		hour := (from.Hour() + i) % 24
+		pprod[i] = f.Pprod[hour%len(f.Pprod)]
+		pload[i] = f.Pload[hour%len(f.Pload)]
+	}
+
+	return pprod, pload, nil
+}
+
+// MPCController plans ESS dispatch over a receding horizon: each tick it solves a small LP over
+// the forecast window (minimize projected grid cost subject to the per-step power limits, the
+// site import cap, and the SOC trajectory staying within [0, Capacity]) and only commits the
+// first step's control, then re-plans next tick with a fresh forecast. It is selected via config
+// and falls back to the threshold/tariff heuristics when forecasts, a tariff, or a feasible plan
+// are unavailable.
+type MPCController struct {
+	Forecaster Forecaster
+	Horizon    int           // number of steps, e.g. 24
+	Step       time.Duration // duration of each horizon step, e.g. time.Hour
+}
+
+// MPCConfig configures the optional receding-horizon planner and its persistence-baseline
+// Forecaster.
+type MPCConfig struct {
+	Horizon int           `json:"horizon" yaml:"horizon" toml:"horizon"` // number of steps, e.g. 24
+	Step    time.Duration `json:"step" yaml:"step" toml:"step"`          // duration of each step, defaults to time.Hour
+
+	Pprod []float64 `json:"pprod" yaml:"pprod" toml:"pprod"` // yesterday's hourly Pprod samples fed to PersistenceForecaster
+	Pload []float64 `json:"pload" yaml:"pload" toml:"pload"` // yesterday's hourly Pload samples fed to PersistenceForecaster
+}
+
+// Build returns the configured MPCController, or nil if no horizon is configured.
+func (c MPCConfig) Build() *MPCController {
+	if c.Horizon <= 0 {
+		return nil
+	}
+
+	step := c.Step
+	if step <= 0 {
+		step = time.Hour
+	}
+
+	return &MPCController{
+		Forecaster: PersistenceForecaster{Pprod: c.Pprod, Pload: c.Pload},
+		Horizon:    c.Horizon,
+		Step:       step,
+	}
+}
+
+// Dispatch returns the ESS power delta to apply this tick (the first control of the receding
+// horizon plan), or ok=false when it has nothing to work with and the caller should fall back
+// to ESS.BalanceEnergy / ESS.BalanceEnergyTariff.
+func (c MPCController) Dispatch(ctx context.Context, ess ESS, pocMax float64, tariff Tariff, now time.Time) (delta float64, ok bool, err error) {
+	if c.Forecaster == nil || tariff == nil || c.Horizon <= 0 {
+		return 0, false, nil
+	}
+
+	pprod, pload, err := c.Forecaster.Forecast(ctx, now, c.Horizon)
+	if err != nil {
+		return 0, false, err
+	}
+
+	rates, err := tariff.Rates(ctx, now, now.Add(time.Duration(c.Horizon)*c.Step))
+	if err != nil {
+		return 0, false, err
+	}
+
+	if len(rates) < c.Horizon {
+		return 0, false, nil
+	}
+
+	delta, ok = planHorizon(ess, pocMax, pprod, pload, rates[:c.Horizon], c.Step)
+
+	return delta, ok, nil
+}
+
+// planHorizon solves the receding-horizon LP and returns its first step's control, P_0 = u_0 -
+// v_0 (ok=false if no feasible plan exists). Discharge and charge are split into separate
+// non-negative decision variables u_t/v_t (h of each) rather than one signed P_t per step, since
+// that is what keeps the problem linear: P_t = u_t - v_t matches ESS.P's sign convention
+// (positive discharge, negative charge).
+//
+// Per step t the LP carries five constraints: u_t <= dischMax; v_t <= chargeMax; the cumulative
+// prefix sum S_t = sum_{s<=t}(u_s - v_s) bounded so that E_{t+1} = E_0 - eta*dt*S_t stays within
+// [0, Capacity]; and a site-import-cap row -u_t + v_t <= (pprod[t]+pload[t]) - pocMax, i.e.
+// Ppoc_t = u_t - v_t + pprod[t] + pload[t] >= pocMax with pload negative for consumption (the sign
+// GetPLoad returns) and pocMax negative, which is the forecast-driven constraint that the planned trajectory never asks for more import than
+// PMaxSite allows. The objective minimizes projected grid cost, -rates[t].Price*dt per unit of
+// u_t and +rates[t].Price*dt per unit of v_t, so discharging is rewarded in proportion to price
+// and charging is penalized in proportion to price, letting the LP arbitrage the spread across
+// the horizon rather than react to a single step.
+//
+// eta folds ChargeEfficiency and DischargeEfficiency into one combined round-trip factor: a
+// linear program can't branch on the sign of P_t to apply one efficiency only while charging and
+// the other only while discharging without losing linearity, so the charge multiplier
+// (ChargeEfficiency) and the discharge multiplier (1/DischargeEfficiency) are averaged instead.
+// This is a documented approximation, not a bug - over a horizon mixing both directions it tracks
+// total losses closely enough to plan around.
+func planHorizon(ess ESS, pocMax float64, pprod []float64, pload []float64, rates []Rate, step time.Duration) (float64, bool) {
+	h := len(rates)
+	if h == 0 || len(pprod) < h || len(pload) < h {
+		return 0, false
+	}
+
+	dt := step.Hours()
+	if dt <= 0 {
+		return 0, false
+	}
+
+	eta := 1.0
+	if ess.ChargeEfficiency > 0 && ess.DischargeEfficiency > 0 {
+		eta = (ess.ChargeEfficiency + 1/ess.DischargeEfficiency) / 2
+	}
+
+	dischMax := max(ess.PmaxDisch, 0)
+	chargeMax := max(-ess.PmaxCh, 0)
+
+	sMax := ess.E / (eta * dt)
+	sMin := (ess.E - ess.Capacity) / (eta * dt)
+
+	n := 2 * h
+
+	c := make([]float64, n)
+	for t := 0; t < h; t++ {
+		c[t] = -rates[t].Price * dt
+		c[h+t] = rates[t].Price * dt
+	}
+
+	var A [][]float64
+	var b []float64
+
+	addRow := func(row []float64, rhs float64) {
+		A = append(A, row)
+		b = append(b, rhs)
+	}
+
+	for t := 0; t < h; t++ {
+		dischRow := make([]float64, n)
+		dischRow[t] = 1
+		addRow(dischRow, dischMax)
+
+		chargeRow := make([]float64, n)
+		chargeRow[h+t] = 1
+		addRow(chargeRow, chargeMax)
+
+		upperRow := make([]float64, n)
+		lowerRow := make([]float64, n)
+		for s := 0; s <= t; s++ {
+			upperRow[s] = 1
+			upperRow[h+s] = -1
+			lowerRow[s] = -1
+			lowerRow[h+s] = 1
+		}
+		addRow(upperRow, sMax)
+		addRow(lowerRow, -sMin)
+
+		siteRow := make([]float64, n)
+		siteRow[t] = -1
+		siteRow[h+t] = 1
+		addRow(siteRow, (pprod[t]+pload[t])-pocMax)
+	}
+
+	x, ok := solveLP(c, A, b)
+	if !ok {
+		return 0, false
+	}
+
+	return x[0] - x[h], true
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestPlanHorizonChargesOnCheapThenDischargesOnExpensive covers the core MPC behavior the
+// request asks for: given a horizon that is cheap up front and expensive later, the LP should
+// plan to charge now (to have energy available) and discharge once price rises, not the
+// heuristic's one-step-only percentile bias.
+func TestPlanHorizonChargesOnCheapThenDischargesOnExpensive(t *testing.T) {
+	ess := ESS{
+		PmaxCh:              -10,
+		PmaxDisch:           10,
+		Capacity:            20,
+		E:                   5,
+		ChargeEfficiency:    1,
+		DischargeEfficiency: 1,
+	}
+
+	rates := []Rate{{Price: 0.1}, {Price: 0.1}, {Price: 0.5}, {Price: 0.5}}
+	pprod := make([]float64, 4)
+	pload := make([]float64, 4)
+
+	delta, ok := planHorizon(ess, -1000, pprod, pload, rates, time.Hour)
+	if !ok {
+		t.Fatal("planHorizon: want ok=true")
+	}
+
+	if delta >= 0 {
+		t.Errorf("delta = %v, want < 0 (charge) on the cheap first step", delta)
+	}
+}
+
+// TestPlanHorizonRespectsSiteImportCap covers the site-import-cap constraint: charging must not
+// be planned beyond what pocMax (a negative import cap) allows given the load/PV forecast.
+func TestPlanHorizonRespectsSiteImportCap(t *testing.T) {
+	ess := ESS{
+		PmaxCh:              -10,
+		PmaxDisch:           10,
+		Capacity:            20,
+		E:                   5,
+		ChargeEfficiency:    1,
+		DischargeEfficiency: 1,
+	}
+
+	rates := []Rate{{Price: 0.1}}
+	pprod := []float64{0}
+	pload := []float64{0}
+
+	// pocMax=-1: at most 1kW of import is allowed, and with no load/PV the only way to charge
+	// is to import, so the charge magnitude must not exceed 1kW.
+	delta, ok := planHorizon(ess, -1, pprod, pload, rates, time.Hour)
+	if !ok {
+		t.Fatal("planHorizon: want ok=true")
+	}
+
+	if delta < -1-1e-6 {
+		t.Errorf("delta = %v, want >= -1 (site import cap)", delta)
+	}
+}
+
+// TestPlanHorizonRejectsShortForecast covers the guard against a pprod/pload forecast shorter
+// than the rate horizon, rather than indexing out of range.
+func TestPlanHorizonRejectsShortForecast(t *testing.T) {
+	ess := ESS{PmaxCh: -10, PmaxDisch: 10, Capacity: 20, E: 5}
+
+	if _, ok := planHorizon(ess, -1000, []float64{0}, []float64{0}, []Rate{{Price: 0.1}, {Price: 0.2}}, time.Hour); ok {
+		t.Fatal("planHorizon: want ok=false when pprod/pload are shorter than rates")
+	}
+}
+
+type stubForecaster struct {
+	pprod, pload []float64
+	err          error
+}
+
+func (f stubForecaster) Forecast(_ context.Context, _ time.Time, horizon int) ([]float64, []float64, error) {
+	if f.err != nil {
+		return nil, nil, f.err
+	}
+
+	return f.pprod, f.pload, nil
+}
+
+// TestDispatchFallsBackWithoutForecaster covers Dispatch's ok=false fallback path used when the
+// caller (EMS.Serve) should fall back to the tariff heuristic.
+func TestDispatchFallsBackWithoutForecaster(t *testing.T) {
+	c := MPCController{Horizon: 4, Step: time.Hour}
+
+	_, ok, err := c.Dispatch(context.Background(), ESS{}, -1000, StaticTariff{}, time.Now())
+	if err != nil {
+		t.Fatalf("Dispatch: %v", err)
+	}
+
+	if ok {
+		t.Fatal("Dispatch: want ok=false with no Forecaster configured")
+	}
+}
+
+// TestDispatchUsesOnlyHorizonRates covers the rates[:c.Horizon] slicing fix: Tariff.Rates may
+// return more than Horizon rates (e.g. a schedule extending past the window), and Dispatch must
+// not hand the LP more steps than it has forecast samples for.
+func TestDispatchUsesOnlyHorizonRates(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	// now falls mid-slot, so Rates over [now, now+2h) overlaps three hourly slots, one more than
+	// Horizon.
+	now := start.Add(30 * time.Minute)
+
+	schedule := make([]Rate, 0, 10)
+	for i := 0; i < 10; i++ {
+		schedule = append(schedule, Rate{
+			Start: start.Add(time.Duration(i) * time.Hour),
+			End:   start.Add(time.Duration(i+1) * time.Hour),
+			Price: 0.1,
+		})
+	}
+
+	c := MPCController{
+		Forecaster: stubForecaster{pprod: []float64{0, 0}, pload: []float64{0, 0}},
+		Horizon:    2,
+		Step:       time.Hour,
+	}
+
+	_, ok, err := c.Dispatch(context.Background(), ESS{PmaxCh: -10, PmaxDisch: 10, Capacity: 20, E: 5}, -1000, StaticTariff{Schedule: schedule}, now)
+	if err != nil {
+		t.Fatalf("Dispatch: %v", err)
+	}
+
+	if !ok {
+		t.Fatal("Dispatch: want ok=true")
+	}
+}
@@ -10,11 +10,13 @@ type PV struct {
 
 	Peak float64 `json:"peak"` // peak power in kW
 
+	InverterACRating float64 `json:"inverteracrating"` // shared inverter AC rating in kW: PV DC production charging the ESS beyond this never reaches the AC side
+
 	SetPointP float64 `json:"setpointp"` // inverter active power setpoint computed by the EMS in kW (AC side, necessarily >= 0 by convention)
 }
 
 func (pv PV) String() string {
-	return fmt.Sprintf("P: %.2f, Pprod: %.2f, Peak: %.2f, SetPointP: %.2f\n", pv.P, pv.Pprod, pv.Peak, pv.SetPointP)
+	return fmt.Sprintf("P: %.2f, Pprod: %.2f, Peak: %.2f, InverterACRating: %.2f, SetPointP: %.2f\n", pv.P, pv.Pprod, pv.Peak, pv.InverterACRating, pv.SetPointP)
 }
 
 // GetMeasure() returns Ppv, Pprod
@@ -0,0 +1,176 @@
+package main
+
+// lpBigM, lpEps and lpIters tune solveLP: lpBigM is the penalty cost attached to artificial
+// variables (large enough that any feasible solution without them is preferred), lpEps is the
+// tolerance used for all zero/sign comparisons against floating-point round-off, and lpIters
+// bounds the pivot loop so a malformed problem fails fast instead of spinning forever.
+const (
+	lpBigM  = 1e6
+	lpEps   = 1e-9
+	lpIters = 1000
+)
+
+// solveLP finds x >= 0 minimizing c.x subject to A x <= b (row by row), using a Big-M simplex on
+// a dense tableau. Rows with a negative b are really >= constraints once negated back to a
+// non-negative right-hand side, so they get a surplus + artificial variable pair instead of a
+// plain slack, letting the origin x=0 be infeasible for those rows without the solver breaking.
+// ok is false if the dimensions don't match, the problem is infeasible or unbounded, or the
+// pivot loop doesn't converge within lpIters iterations.
+func solveLP(c []float64, A [][]float64, b []float64) (x []float64, ok bool) {
+	n := len(c)
+	m := len(A)
+
+	if m != len(b) {
+		return nil, false
+	}
+
+	for _, row := range A {
+		if len(row) != n {
+			return nil, false
+		}
+	}
+
+	negated := make([]bool, m)
+
+	var numSurplus, numSlack, numArtificial int
+	for i, bi := range b {
+		if bi < 0 {
+			negated[i] = true
+			numSurplus++
+			numArtificial++
+		} else {
+			numSlack++
+		}
+	}
+
+	surplusCol := n
+	slackCol := surplusCol + numSurplus
+	artificialCol := slackCol + numSlack
+	totalCols := artificialCol + numArtificial
+
+	tab := make([][]float64, m+1) // tab[m] is the objective row
+	for i := range tab {
+		tab[i] = make([]float64, totalCols+1) // the last column is the RHS
+	}
+
+	basis := make([]int, m)
+
+	var si, sli, ai int
+	for i := 0; i < m; i++ {
+		row := tab[i]
+
+		sign := 1.0
+		if negated[i] {
+			sign = -1
+		}
+
+		for j := 0; j < n; j++ {
+			row[j] = sign * A[i][j]
+		}
+		row[totalCols] = sign * b[i]
+
+		if negated[i] {
+			row[surplusCol+si] = -1
+			row[artificialCol+ai] = 1
+			basis[i] = artificialCol + ai
+			si++
+			ai++
+		} else {
+			row[slackCol+sli] = 1
+			basis[i] = slackCol + sli
+			sli++
+		}
+	}
+
+	obj := tab[m]
+	for j := 0; j < n; j++ {
+		obj[j] = c[j]
+	}
+
+	for j := 0; j < numArtificial; j++ {
+		obj[artificialCol+j] = lpBigM
+	}
+
+	// Price out the Big-M cost of the variables that start out basic (every artificial), the
+	// same way any basic variable's column must read zero in the objective row before pivoting.
+	for i := 0; i < m; i++ {
+		if basis[i] >= artificialCol {
+			for j := 0; j <= totalCols; j++ {
+				obj[j] -= lpBigM * tab[i][j]
+			}
+		}
+	}
+
+	for iter := 0; iter < lpIters; iter++ {
+		// Bland's rule: the first column with a negative reduced cost, not the most negative,
+		// trades a slower descent for a guarantee against cycling.
+		pivotCol := -1
+		for j := 0; j < totalCols; j++ {
+			if obj[j] < -lpEps {
+				pivotCol = j
+				break
+			}
+		}
+
+		if pivotCol == -1 {
+			break // optimal: no column can still improve the objective
+		}
+
+		pivotRow := -1
+		bestRatio := 0.0
+
+		for i := 0; i < m; i++ {
+			if tab[i][pivotCol] <= lpEps {
+				continue
+			}
+
+			ratio := tab[i][totalCols] / tab[i][pivotCol]
+			if pivotRow == -1 || ratio < bestRatio-lpEps ||
+				(ratio < bestRatio+lpEps && basis[i] < basis[pivotRow]) {
+				pivotRow = i
+				bestRatio = ratio
+			}
+		}
+
+		if pivotRow == -1 {
+			return nil, false // unbounded
+		}
+
+		pivotVal := tab[pivotRow][pivotCol]
+		for j := 0; j <= totalCols; j++ {
+			tab[pivotRow][j] /= pivotVal
+		}
+
+		for i := 0; i <= m; i++ {
+			if i == pivotRow {
+				continue
+			}
+
+			factor := tab[i][pivotCol]
+			if factor == 0 {
+				continue
+			}
+
+			for j := 0; j <= totalCols; j++ {
+				tab[i][j] -= factor * tab[pivotRow][j]
+			}
+		}
+
+		basis[pivotRow] = pivotCol
+	}
+
+	for i := 0; i < m; i++ {
+		if basis[i] >= artificialCol && tab[i][totalCols] > lpEps {
+			return nil, false // an artificial variable couldn't be driven out: infeasible
+		}
+	}
+
+	x = make([]float64, n)
+	for i := 0; i < m; i++ {
+		if basis[i] < n {
+			x[basis[i]] = tab[i][totalCols]
+		}
+	}
+
+	return x, true
+}
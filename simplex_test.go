@@ -0,0 +1,85 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func approxEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+// TestSolveLPPlainSlacks covers the easy case: every row has a non-negative RHS, so every row
+// gets a plain slack and the origin is already feasible.
+//
+// minimize -x - 2y subject to x + y <= 4, x <= 3 -> optimum at x=0, y=4, objective -8.
+func TestSolveLPPlainSlacks(t *testing.T) {
+	x, ok := solveLP(
+		[]float64{-1, -2},
+		[][]float64{{1, 1}, {1, 0}},
+		[]float64{4, 3},
+	)
+	if !ok {
+		t.Fatal("solveLP: want ok=true")
+	}
+
+	if !approxEqual(x[0], 0) || !approxEqual(x[1], 4) {
+		t.Errorf("x = %v, want [0 4]", x)
+	}
+}
+
+// TestSolveLPNegativeRHS covers a row with negative RHS, which forces solveLP's surplus +
+// artificial-variable path since the origin is infeasible for that row.
+//
+// minimize x + y subject to -x - y <= -2 (i.e. x + y >= 2), x <= 5, y <= 5 -> optimum objective 2,
+// e.g. x=2, y=0.
+func TestSolveLPNegativeRHS(t *testing.T) {
+	x, ok := solveLP(
+		[]float64{1, 1},
+		[][]float64{{-1, -1}, {1, 0}, {0, 1}},
+		[]float64{-2, 5, 5},
+	)
+	if !ok {
+		t.Fatal("solveLP: want ok=true")
+	}
+
+	if sum := x[0] + x[1]; !approxEqual(sum, 2) {
+		t.Errorf("x[0]+x[1] = %v, want 2 (x=%v)", sum, x)
+	}
+}
+
+// TestSolveLPInfeasible covers a contradictory pair of constraints: x <= 1 and x >= 3 can't both
+// hold, so no artificial variable can be driven out of the basis.
+func TestSolveLPInfeasible(t *testing.T) {
+	_, ok := solveLP(
+		[]float64{1},
+		[][]float64{{1}, {-1}},
+		[]float64{1, -3},
+	)
+	if ok {
+		t.Fatal("solveLP: want ok=false for an infeasible problem")
+	}
+}
+
+// TestSolveLPUnbounded covers a problem with no lower bound on the objective: minimizing -x with
+// only a lower bound (x >= 0, implicit) and no upper bound on x.
+func TestSolveLPUnbounded(t *testing.T) {
+	_, ok := solveLP(
+		[]float64{-1},
+		[][]float64{{0}},
+		[]float64{0},
+	)
+	if ok {
+		t.Fatal("solveLP: want ok=false for an unbounded problem")
+	}
+}
+
+func TestSolveLPDimensionMismatch(t *testing.T) {
+	if _, ok := solveLP([]float64{1, 1}, [][]float64{{1}}, []float64{1}); ok {
+		t.Fatal("solveLP: want ok=false for mismatched A row width")
+	}
+
+	if _, ok := solveLP([]float64{1}, [][]float64{{1}, {1}}, []float64{1}); ok {
+		t.Fatal("solveLP: want ok=false for mismatched A/b length")
+	}
+}
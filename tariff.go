@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"time"
+)
+
+// awattarFetchTimeout bounds FetchAwattarSchedule: TariffConfig.Build is called from
+// config.Validate during process startup, and a hung/unreachable price endpoint must not hang
+// startup indefinitely. A var, not a const, so tests can shorten it.
+var awattarFetchTimeout = 10 * time.Second
+
+// Rate is a price/carbon datapoint valid over [Start, End).
+type Rate struct {
+	Start time.Time `json:"start" yaml:"start" toml:"start"`
+	End   time.Time `json:"end" yaml:"end" toml:"end"`
+	Price float64   `json:"price" yaml:"price" toml:"price"` // currency per kWh
+	CO2   float64   `json:"co2" yaml:"co2" toml:"co2"`       // kg CO2 per kWh
+}
+
+// Tariff is the pricing signal EMS.Serve consults when StrategyTariff is selected: it biases
+// ESS.SetPointP toward charging (even from the grid) when Current is below Average, and toward
+// discharging to serve load when it is above. The threshold-based heuristic in ESS.BalanceEnergy
+// remains the default strategy and does not require a Tariff.
+type Tariff interface {
+	// Rates returns the known rates covering [from, to).
+	Rates(ctx context.Context, from time.Time, to time.Time) ([]Rate, error)
+	// Current returns the rate in effect at now.
+	Current(now time.Time) (Rate, error)
+	// Average returns the rolling average price used as the charge/discharge threshold.
+	Average() (float64, error)
+}
+
+// StaticTariff is a Tariff backed by a fixed, pre-loaded schedule of Rates, e.g. a day-ahead
+// schedule fetched once at startup from an awattar-like JSON URL, or loaded from config.
+type StaticTariff struct {
+	Schedule []Rate
+}
+
+func (t StaticTariff) Rates(_ context.Context, from time.Time, to time.Time) ([]Rate, error) {
+	rates := make([]Rate, 0, len(t.Schedule))
+
+	for _, r := range t.Schedule {
+		if r.End.After(from) && r.Start.Before(to) {
+			rates = append(rates, r)
+		}
+	}
+
+	return rates, nil
+}
+
+func (t StaticTariff) Current(now time.Time) (Rate, error) {
+	for _, r := range t.Schedule {
+		if !now.Before(r.Start) && now.Before(r.End) {
+			return r, nil
+		}
+	}
+
+	return Rate{}, ErrTariffNoRate{At: now}
+}
+
+func (t StaticTariff) Average() (float64, error) {
+	if len(t.Schedule) == 0 {
+		return 0, ErrTariffNoRate{}
+	}
+
+	var sum float64
+	for _, r := range t.Schedule {
+		sum += r.Price
+	}
+
+	return sum / float64(len(t.Schedule)), nil
+}
+
+// awattarSchedule mirrors the JSON body returned by an awattar-like day-ahead price API: a list
+// of hourly slots priced in EUR/MWh.
+type awattarSchedule struct {
+	Data []struct {
+		StartTimestamp int64   `json:"start_timestamp"` // unix millis
+		EndTimestamp   int64   `json:"end_timestamp"`   // unix millis
+		Marketprice    float64 `json:"marketprice"`     // price for the slot, EUR/MWh
+	} `json:"data"`
+}
+
+// FetchAwattarSchedule fetches a day-ahead schedule from an awattar-like JSON URL and converts
+// it to Rates in currency/kWh (awattar prices are EUR/MWh).
+func FetchAwattarSchedule(ctx context.Context, url string) ([]Rate, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, ErrTariffFetch{URL: url, Status: resp.StatusCode}
+	}
+
+	var schedule awattarSchedule
+	if err := json.NewDecoder(resp.Body).Decode(&schedule); err != nil {
+		return nil, err
+	}
+
+	rates := make([]Rate, 0, len(schedule.Data))
+	for _, slot := range schedule.Data {
+		rates = append(rates, Rate{
+			Start: time.UnixMilli(slot.StartTimestamp),
+			End:   time.UnixMilli(slot.EndTimestamp),
+			Price: slot.Marketprice / 1000, // EUR/MWh -> EUR/kWh
+		})
+	}
+
+	return rates, nil
+}
+
+// FixedTOUTariff is a Tariff with a flat peak/off-peak time-of-use split repeating daily. It is
+// the simplest provider to hand-configure and needs no external schedule.
+type FixedTOUTariff struct {
+	PeakStart time.Duration // offset from local midnight
+	PeakEnd   time.Duration
+
+	PeakPrice    float64
+	OffPeakPrice float64
+}
+
+func (t FixedTOUTariff) isPeak(now time.Time) bool {
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	offset := now.Sub(midnight)
+
+	return offset >= t.PeakStart && offset < t.PeakEnd
+}
+
+func (t FixedTOUTariff) Current(now time.Time) (Rate, error) {
+	if t.isPeak(now) {
+		return Rate{Price: t.PeakPrice}, nil
+	}
+
+	return Rate{Price: t.OffPeakPrice}, nil
+}
+
+// Rates is unsupported: a TOU tariff is defined by rule, not a discrete schedule. Callers
+// needing an explicit list should sample Current over the window instead.
+func (t FixedTOUTariff) Rates(_ context.Context, _ time.Time, _ time.Time) ([]Rate, error) {
+	return nil, ErrTariffUnsupported{Op: "Rates"}
+}
+
+func (t FixedTOUTariff) Average() (float64, error) {
+	return (t.PeakPrice + t.OffPeakPrice) / 2, nil
+}
+
+// TariffConfig selects and configures one of the pluggable Tariff providers.
+type TariffConfig struct {
+	// Provider is one of "" (disabled), "static", "fixedtou" or "awattar".
+	Provider string `json:"provider" yaml:"provider" toml:"provider"`
+
+	Schedule []Rate `json:"schedule" yaml:"schedule" toml:"schedule"` // used by "static"
+
+	PeakStart    time.Duration `json:"peakstart" yaml:"peakstart" toml:"peakstart"`          // used by "fixedtou"
+	PeakEnd      time.Duration `json:"peakend" yaml:"peakend" toml:"peakend"`                // used by "fixedtou"
+	PeakPrice    float64       `json:"peakprice" yaml:"peakprice" toml:"peakprice"`          // used by "fixedtou"
+	OffPeakPrice float64       `json:"offpeakprice" yaml:"offpeakprice" toml:"offpeakprice"` // used by "fixedtou"
+
+	URL string `json:"url" yaml:"url" toml:"url"` // used by "awattar": day-ahead schedule JSON endpoint
+}
+
+// Build returns the configured Tariff, or nil if no provider is configured. For "awattar" it
+// fetches the day-ahead schedule once, the same way a hand-loaded "static" schedule would be
+// populated, and wraps it in a StaticTariff.
+func (c TariffConfig) Build() (Tariff, error) {
+	switch c.Provider {
+	case "":
+		return nil, nil
+	case "static":
+		return StaticTariff{Schedule: c.Schedule}, nil
+	case "fixedtou":
+		return FixedTOUTariff{
+			PeakStart:    c.PeakStart,
+			PeakEnd:      c.PeakEnd,
+			PeakPrice:    c.PeakPrice,
+			OffPeakPrice: c.OffPeakPrice,
+		}, nil
+	case "awattar":
+		if c.URL == "" {
+			return nil, ErrInvalidConfig{Field: "tariff.url", Reason: "must be set when provider is \"awattar\""}
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), awattarFetchTimeout)
+		defer cancel()
+
+		schedule, err := FetchAwattarSchedule(ctx, c.URL)
+		if err != nil {
+			return nil, err
+		}
+
+		return StaticTariff{Schedule: schedule}, nil
+	default:
+		return nil, ErrInvalidConfig{Field: "tariff.provider", Reason: "must be one of \"\", \"static\", \"fixedtou\" or \"awattar\""}
+	}
+}
+
+// BalanceEnergyTariff balances the energy in the ESS using the tariff price signal instead of
+// the poc percentage thresholds used by BalanceEnergy: it biases toward charging (even from the
+// grid) when the current price is below the rolling average, and toward discharging to serve
+// load when it is above. It respects the current Mode the same way BalanceEnergy does.
+func (ess *ESS) BalanceEnergyTariff(poc float64, pocMax float64, tariff Tariff, now time.Time) (float64, Transition, error) {
+	current, err := tariff.Current(now)
+	if err != nil {
+		return 0, Transition{}, err
+	}
+
+	average, err := tariff.Average()
+	if err != nil {
+		return 0, Transition{}, err
+	}
+
+	if current.Price < average && ess.SOC() < 1 && ess.P > ess.PmaxCh {
+		if !ess.canTransitionTo(ModeCharging, now) {
+			return 0, Transition{}, nil
+		}
+
+		transition := ess.setMode(ModeCharging, now)
+
+		// delta is larger than the threshold strategy's: a cheap-hour window is an
+		// opportunity to import from the grid, not just to absorb PV excess.
+		delta := -min(-ess.PmaxCh/10, math.Abs(pocMax)/10)
+		ess.AdjustDischarge(delta, now)
+
+		return delta, transition, nil
+	}
+
+	if current.Price > average && ess.SOC() > 0 && ess.P < ess.PmaxDisch {
+		if !ess.canTransitionTo(ModeDischarging, now) {
+			return 0, Transition{}, nil
+		}
+
+		transition := ess.setMode(ModeDischarging, now)
+
+		delta := min(ess.PmaxDisch/10, math.Abs(pocMax)/10)
+		ess.AdjustDischarge(delta, now)
+
+		return delta, transition, nil
+	}
+
+	return 0, Transition{}, nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestFetchAwattarSchedule(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":[
+			{"start_timestamp":1000,"end_timestamp":3601000,"marketprice":100},
+			{"start_timestamp":3601000,"end_timestamp":7201000,"marketprice":50}
+		]}`))
+	}))
+	defer srv.Close()
+
+	rates, err := FetchAwattarSchedule(context.Background(), srv.URL)
+	if err != nil {
+		t.Fatalf("FetchAwattarSchedule: %v", err)
+	}
+
+	if len(rates) != 2 {
+		t.Fatalf("len(rates) = %d, want 2", len(rates))
+	}
+
+	// marketprice is EUR/MWh; Rate.Price is currency/kWh.
+	if rates[0].Price != 0.1 {
+		t.Errorf("rates[0].Price = %v, want 0.1", rates[0].Price)
+	}
+
+	if !rates[0].Start.Equal(time.UnixMilli(1000)) {
+		t.Errorf("rates[0].Start = %v, want %v", rates[0].Start, time.UnixMilli(1000))
+	}
+}
+
+func TestFetchAwattarScheduleNon200(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	_, err := FetchAwattarSchedule(context.Background(), srv.URL)
+	if _, ok := err.(ErrTariffFetch); !ok {
+		t.Fatalf("err = %v (%T), want ErrTariffFetch", err, err)
+	}
+}
+
+// TestTariffConfigBuildAwattarTimesOut covers the bounded-context fix: a hung price endpoint
+// must not hang TariffConfig.Build (and therefore config.Validate) indefinitely.
+func TestTariffConfigBuildAwattarTimesOut(t *testing.T) {
+	block := make(chan struct{})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-block
+	}))
+	defer srv.Close()
+	defer close(block) // unblock the handler before Close waits for it to finish
+
+	orig := awattarFetchTimeout
+	awattarFetchTimeout = 50 * time.Millisecond
+	defer func() { awattarFetchTimeout = orig }()
+
+	c := TariffConfig{Provider: "awattar", URL: srv.URL}
+
+	start := time.Now()
+	if _, err := c.Build(); err == nil {
+		t.Fatal("Build() = nil error, want a timeout error")
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Build() took %v, want it bounded by awattarFetchTimeout", elapsed)
+	}
+}